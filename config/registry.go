@@ -0,0 +1,76 @@
+package config
+
+import "time"
+
+// Registry configures which service discovery backend fabio pulls routes
+// from, selected by Backend ("consul", "file", "static", "etcd" or "zk"),
+// and that backend's own settings.
+type Registry struct {
+	Backend   string
+	File      File
+	Static    Static
+	Consul    Consul
+	Etcd      Etcd
+	Zookeeper Zookeeper
+}
+
+// File configures the file backend.
+type File struct {
+	Path string
+}
+
+// Static configures the static backend.
+type Static struct {
+	Routes string
+}
+
+// Etcd configures the etcd backend.
+type Etcd struct {
+	Addr string
+	Path string
+}
+
+// Zookeeper configures the zk backend.
+type Zookeeper struct {
+	Addr string
+	Path string
+}
+
+// Consul configures the consul backend.
+type Consul struct {
+	Addr          string
+	Scheme        string
+	Token         string
+	KVPath        string
+	TagPrefix     string
+	Register      bool
+	ServiceAddr   string
+	ServiceName   string
+	ServiceTags   []string
+	ServiceStatus []string
+	CheckInterval time.Duration
+	CheckTimeout  time.Duration
+
+	// CheckDeregisterCriticalServiceAfter tells the agent to
+	// automatically deregister fabio if its health check has been
+	// critical for this long, giving ops a grace window before a flapping
+	// fabio instance is pulled out of the catalog.
+	CheckDeregisterCriticalServiceAfter time.Duration
+
+	// Connect enables dialing Connect-tagged upstreams over the mTLS
+	// transport fetched from Consul's Connect CA instead of fabio's
+	// shared, plaintext transport.
+	Connect bool
+
+	// DCPolicy controls which datacenters are considered when building
+	// routes: "local" (only the local datacenter), "failover" (remote
+	// datacenters only for a service with no passing local instance) or
+	// "all" (every datacenter, the default).
+	DCPolicy string
+
+	// WatchMode selects how the backend watches Consul for changes:
+	// "global" polls Health().State("any") on every change, as fabio has
+	// always done; "per-service" fans out to one blocking watch per
+	// service instead.
+	WatchMode string
+}
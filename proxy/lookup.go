@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// Target is a single destination a route can proxy a request to. It
+// satisfies WeightedTarget so the weighted/sticky strategy in sticky.go
+// can choose among the pool registered for a route.
+type Target struct {
+	URL    *url.URL
+	Weight float64
+	Sticky string
+	Timer  gometrics.Timer
+}
+
+// GetWeight implements WeightedTarget.
+func (t *Target) GetWeight() float64 { return t.Weight }
+
+// GetSticky implements WeightedTarget.
+func (t *Target) GetSticky() string { return t.Sticky }
+
+var (
+	tableMu sync.RWMutex
+	table   = map[string][]*Target{}
+)
+
+// SetTargets replaces the pool of targets serving requests whose path
+// has the prefix src, e.g. "/foo". Passing a nil or empty targets
+// removes src from the table.
+func SetTargets(src string, targets []*Target) {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	if len(targets) == 0 {
+		delete(table, src)
+		return
+	}
+	table[src] = targets
+}
+
+// target picks the Target that should serve r. It matches r.URL.Path
+// against the longest registered prefix, then uses PickWeighted to
+// choose among that prefix's pool, so weight= and sticky= tags on a
+// route's targets actually affect which one is picked. It returns nil
+// if no prefix matches or the matching pool is empty.
+func target(r *http.Request) *Target {
+	tableMu.RLock()
+	defer tableMu.RUnlock()
+
+	var pool []*Target
+	bestLen := -1
+	for src, targets := range table {
+		if !strings.HasPrefix(r.URL.Path, src) {
+			continue
+		}
+		if len(src) > bestLen {
+			bestLen = len(src)
+			pool = targets
+		}
+	}
+	if len(pool) == 0 {
+		return nil
+	}
+
+	wt := make([]WeightedTarget, len(pool))
+	for i, t := range pool {
+		wt[i] = t
+	}
+
+	idx := PickWeighted(wt, r)
+	if idx < 0 {
+		return nil
+	}
+	return pool[idx]
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func mustURL(t *testing.T, raw string) *url.URL {
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestTargetNoMatch(t *testing.T) {
+	r := httptest.NewRequest("GET", "/nope", nil)
+	if got := target(r); got != nil {
+		t.Fatalf("got %v want nil", got)
+	}
+}
+
+func TestTargetPicksLongestPrefix(t *testing.T) {
+	root := &Target{URL: mustURL(t, "http://127.0.0.1:1111/")}
+	foo := &Target{URL: mustURL(t, "http://127.0.0.1:2222/")}
+	SetTargets("/", []*Target{root})
+	SetTargets("/foo", []*Target{foo})
+	defer SetTargets("/", nil)
+	defer SetTargets("/foo", nil)
+
+	r := httptest.NewRequest("GET", "/foo/bar", nil)
+	if got := target(r); got != foo {
+		t.Fatalf("got %v want the /foo target", got)
+	}
+}
+
+func TestTargetSticky(t *testing.T) {
+	a := &Target{URL: mustURL(t, "http://127.0.0.1:1111/"), Weight: 0.5, Sticky: "cookie:SID"}
+	b := &Target{URL: mustURL(t, "http://127.0.0.1:2222/"), Weight: 0.5}
+	SetTargets("/sticky", []*Target{a, b})
+	defer SetTargets("/sticky", nil)
+
+	r := httptest.NewRequest("GET", "/sticky", nil)
+	r.AddCookie(&http.Cookie{Name: "SID", Value: "same-client"})
+
+	first := target(r)
+	for i := 0; i < 10; i++ {
+		if got := target(r); got != first {
+			t.Fatalf("got target %v, want the same %v every time for a sticky client", got, first)
+		}
+	}
+}
@@ -14,6 +14,12 @@ type Proxy struct {
 	tr       http.RoundTripper
 	cfg      config.Proxy
 	requests gometrics.Timer
+
+	// Transport, if set, is consulted for every request to pick the
+	// http.RoundTripper to dial t with. It returns nil to fall back to
+	// the shared transport. A registry backend sets this when it needs
+	// per-target routing, e.g. Consul Connect dialing over mTLS.
+	Transport func(t *Target) http.RoundTripper
 }
 
 func New(tr http.RoundTripper, cfg config.Proxy) *Proxy {
@@ -41,6 +47,13 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tr := p.tr
+	if p.Transport != nil {
+		if custom := p.Transport(t); custom != nil {
+			tr = custom
+		}
+	}
+
 	var h http.Handler
 	switch {
 	case r.Header.Get("Upgrade") == "websocket":
@@ -52,10 +65,10 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// use the flush interval for SSE (server-sent events)
 	// must be > 0s to be effective
 	case r.Header.Get("Accept") == "text/event-stream":
-		h = newHTTPProxy(t.URL, p.tr, p.cfg.FlushInterval)
+		h = newHTTPProxy(t.URL, tr, p.cfg.FlushInterval)
 
 	default:
-		h = newHTTPProxy(t.URL, p.tr, time.Duration(0))
+		h = newHTTPProxy(t.URL, tr, time.Duration(0))
 	}
 
 	start := time.Now()
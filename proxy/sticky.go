@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// StickyKey extracts the value used for hash-based stickiness from a
+// request, given a route's sticky spec such as "cookie:SID",
+// "header:X-User" or "ip". It returns ok=false if the spec is malformed
+// or the requested value is absent from the request, in which case the
+// caller should fall back to plain weighted selection.
+func StickyKey(r *http.Request, sticky string) (string, bool) {
+	parts := strings.SplitN(sticky, ":", 2)
+	kind := parts[0]
+
+	switch kind {
+	case "cookie":
+		if len(parts) != 2 {
+			return "", false
+		}
+		c, err := r.Cookie(parts[1])
+		if err != nil {
+			return "", false
+		}
+		return c.Value, true
+
+	case "header":
+		if len(parts) != 2 {
+			return "", false
+		}
+		if v := r.Header.Get(parts[1]); v != "" {
+			return v, true
+		}
+		return "", false
+
+	case "ip":
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr, true
+		}
+		return host, true
+
+	default:
+		return "", false
+	}
+}
+
+// StickyHash returns a deterministic value in [0,1) for key. Mapping it
+// onto a target pool's cumulative weights, as PickWeighted does, keeps
+// the same client on the same target for as long as the pool's weights
+// don't change.
+func StickyHash(key string) float64 {
+	sum := sha256.Sum256([]byte(key))
+	n := binary.BigEndian.Uint64(sum[:8])
+	// keep the top 53 bits, which a float64 holds exactly, so the
+	// result stays below 1
+	return float64(n>>11) / (1 << 53)
+}
+
+// WeightedTarget is the subset of a route's candidate target that
+// PickWeighted needs to choose one. proxy's Target type (lookup.go)
+// satisfies it, so the Weight/Sticky strategy can be wired in next to
+// the existing round-robin and random ones.
+type WeightedTarget interface {
+	GetWeight() float64
+	GetSticky() string
+}
+
+// PickWeighted returns the index into pool chosen by weight, so a target
+// tagged weight=0.1 receives roughly a tenth of requests. If any target
+// in pool carries a sticky spec that resolves against r via StickyKey,
+// that hash is used instead of a random draw, so the same client keeps
+// landing on the same target. It returns -1 for an empty pool, and falls
+// back to an even split if every target has weight <= 0.
+func PickWeighted(pool []WeightedTarget, r *http.Request) int {
+	if len(pool) == 0 {
+		return -1
+	}
+
+	var total float64
+	for _, t := range pool {
+		total += t.GetWeight()
+	}
+
+	h := rand.Float64()
+	for _, t := range pool {
+		sticky := t.GetSticky()
+		if sticky == "" {
+			continue
+		}
+		if key, ok := StickyKey(r, sticky); ok {
+			h = StickyHash(key)
+			break
+		}
+	}
+
+	if total <= 0 {
+		idx := int(h * float64(len(pool)))
+		if idx >= len(pool) {
+			idx = len(pool) - 1
+		}
+		return idx
+	}
+
+	target := h * total
+	var cum float64
+	for i, t := range pool {
+		cum += t.GetWeight()
+		if target < cum {
+			return i
+		}
+	}
+	return len(pool) - 1
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStickyKey(t *testing.T) {
+	cookieReq := httptest.NewRequest("GET", "/", nil)
+	cookieReq.AddCookie(&http.Cookie{Name: "SID", Value: "abc"})
+
+	headerReq := httptest.NewRequest("GET", "/", nil)
+	headerReq.Header.Set("X-User", "bob")
+
+	ipReq := httptest.NewRequest("GET", "/", nil)
+	ipReq.RemoteAddr = "1.2.3.4:5678"
+
+	tests := []struct {
+		name   string
+		r      *http.Request
+		sticky string
+		key    string
+		ok     bool
+	}{
+		{"cookie present", cookieReq, "cookie:SID", "abc", true},
+		{"cookie missing", httptest.NewRequest("GET", "/", nil), "cookie:SID", "", false},
+		{"cookie spec missing name", cookieReq, "cookie", "", false},
+		{"header present", headerReq, "header:X-User", "bob", true},
+		{"header missing", httptest.NewRequest("GET", "/", nil), "header:X-User", "", false},
+		{"ip", ipReq, "ip", "1.2.3.4", true},
+		{"unknown kind", cookieReq, "bogus:x", "", false},
+		{"empty spec", cookieReq, "", "", false},
+	}
+
+	for _, tt := range tests {
+		key, ok := StickyKey(tt.r, tt.sticky)
+		if got, want := ok, tt.ok; got != want {
+			t.Errorf("%s: got ok=%v want %v", tt.name, got, want)
+			continue
+		}
+		if got, want := key, tt.key; got != want {
+			t.Errorf("%s: got %q want %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestStickyHashDeterministic(t *testing.T) {
+	a := StickyHash("client-1")
+	b := StickyHash("client-1")
+	if a != b {
+		t.Fatalf("got different hashes %v and %v for the same key", a, b)
+	}
+	if c := StickyHash("client-2"); c == a {
+		t.Fatalf("got the same hash %v for two different keys", a)
+	}
+	if a < 0 || a >= 1 {
+		t.Fatalf("got %v, want a value in [0,1)", a)
+	}
+}
+
+type fakeTarget struct {
+	weight float64
+	sticky string
+}
+
+func (t fakeTarget) GetWeight() float64 { return t.weight }
+func (t fakeTarget) GetSticky() string  { return t.sticky }
+
+func TestPickWeightedEmptyPool(t *testing.T) {
+	if got := PickWeighted(nil, httptest.NewRequest("GET", "/", nil)); got != -1 {
+		t.Errorf("got %d want -1", got)
+	}
+}
+
+func TestPickWeightedSticky(t *testing.T) {
+	pool := []WeightedTarget{
+		fakeTarget{weight: 0.5, sticky: "cookie:SID"},
+		fakeTarget{weight: 0.5},
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "SID", Value: "same-client"})
+
+	first := PickWeighted(pool, r)
+	for i := 0; i < 10; i++ {
+		if got := PickWeighted(pool, r); got != first {
+			t.Fatalf("got target %d, want the same %d every time for a sticky client", got, first)
+		}
+	}
+}
+
+func TestPickWeightedZeroWeightsSplitEvenly(t *testing.T) {
+	pool := []WeightedTarget{fakeTarget{}, fakeTarget{}, fakeTarget{}}
+	r := httptest.NewRequest("GET", "/", nil)
+
+	for i := 0; i < 50; i++ {
+		if got := PickWeighted(pool, r); got < 0 || got >= len(pool) {
+			t.Fatalf("got out-of-range index %d for a pool of %d", got, len(pool))
+		}
+	}
+}
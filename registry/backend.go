@@ -0,0 +1,36 @@
+// Package registry defines the interface fabio uses to discover routes
+// from a service discovery backend, and the structured Route type shared
+// by every implementation of it.
+package registry
+
+import "context"
+
+// Route is a single routing rule produced by a Backend. It mirrors the
+// fields of a "route add" command without depending on that particular
+// text format. Render and ParseRoute convert between the two.
+type Route struct {
+	Service string
+	Src     string // e.g. "/foo"
+	Dst     string // e.g. "http://1.2.3.4:5678/"
+	DC      string
+	Weight  float64
+	Sticky  string // e.g. "cookie:SID", "header:X-User" or "ip"
+	Tags    []string
+}
+
+// Backend is implemented by every service discovery source fabio can pull
+// routes from: consul, file, static, etcd and zk. registry/backends.New
+// builds the one selected by registry.backend.
+type Backend interface {
+	// Subscribe streams the current set of routes and every subsequent
+	// change until ctx is canceled.
+	Subscribe(ctx context.Context) (<-chan []Route, error)
+
+	// Register makes fabio itself discoverable through this backend, if
+	// the backend supports self-registration. Backends that don't may
+	// implement it as a no-op.
+	Register() error
+
+	// Deregister undoes Register on shutdown.
+	Deregister() error
+}
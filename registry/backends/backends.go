@@ -0,0 +1,73 @@
+// Package backends selects and builds the registry.Backend configured by
+// registry.backend. It is the one place allowed to import every backend
+// package, since each of them already imports registry for Route and
+// Backend and so can't build the selector itself without an import
+// cycle.
+package backends
+
+import (
+	"fmt"
+
+	"github.com/eBay/fabio/config"
+	"github.com/eBay/fabio/proxy"
+	"github.com/eBay/fabio/registry"
+	"github.com/eBay/fabio/registry/consul"
+	"github.com/eBay/fabio/registry/etcd"
+	"github.com/eBay/fabio/registry/file"
+	"github.com/eBay/fabio/registry/static"
+	"github.com/eBay/fabio/registry/zk"
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// New builds the registry.Backend selected by cfg.Backend: "consul",
+// "file", "static", "etcd" or "zk".
+//
+// dcIndex and datacenters carry the Consul-specific runtime state that
+// config.Registry doesn't hold; they are ignored for every other
+// backend. p receives the per-target mTLS transport a Connect-enabled
+// Consul backend installs, if any.
+func New(cfg config.Registry, p *proxy.Proxy, dcIndex int, datacenters []string) (registry.Backend, error) {
+	switch cfg.Backend {
+	case "consul":
+		cc := consulapi.DefaultConfig()
+		if cfg.Consul.Addr != "" {
+			cc.Address = cfg.Consul.Addr
+		}
+		if cfg.Consul.Scheme != "" {
+			cc.Scheme = cfg.Consul.Scheme
+		}
+		if cfg.Consul.Token != "" {
+			cc.Token = cfg.Consul.Token
+		}
+		client, err := consulapi.NewClient(cc)
+		if err != nil {
+			return nil, fmt.Errorf("backends: error creating consul client: %s", err)
+		}
+
+		reg := consul.RegistrarConfig{
+			Enabled:                             cfg.Consul.Register,
+			ServiceAddr:                         cfg.Consul.ServiceAddr,
+			ServiceName:                         cfg.Consul.ServiceName,
+			ServiceTags:                         cfg.Consul.ServiceTags,
+			CheckInterval:                       cfg.Consul.CheckInterval,
+			CheckTimeout:                        cfg.Consul.CheckTimeout,
+			CheckDeregisterCriticalServiceAfter: cfg.Consul.CheckDeregisterCriticalServiceAfter,
+		}
+		return consul.NewBackend(client, cfg.Consul.TagPrefix, cfg.Consul.ServiceStatus, dcIndex, datacenters, cfg.Consul.Connect, cfg.Consul.DCPolicy, cfg.Consul.WatchMode, reg, p), nil
+
+	case "file":
+		return file.NewBackend(cfg.File.Path)
+
+	case "static":
+		return static.NewBackend(cfg.Static.Routes)
+
+	case "etcd":
+		return etcd.NewBackend(cfg.Etcd.Addr, cfg.Etcd.Path)
+
+	case "zk":
+		return zk.NewBackend(cfg.Zookeeper.Addr, cfg.Zookeeper.Path)
+
+	default:
+		return nil, fmt.Errorf("backends: unknown registry.backend %q", cfg.Backend)
+	}
+}
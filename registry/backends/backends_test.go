@@ -0,0 +1,35 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/eBay/fabio/config"
+)
+
+func TestNewUnknownBackend(t *testing.T) {
+	_, err := New(config.Registry{Backend: "bogus"}, nil, 0, nil)
+	if err == nil {
+		t.Fatal("got nil error, want one for an unknown backend")
+	}
+}
+
+func TestNewStatic(t *testing.T) {
+	cfg := config.Registry{
+		Backend: "static",
+		Static:  config.Static{Routes: "route add svc / http://127.0.0.1:6666/"},
+	}
+	b, err := New(cfg, nil, 0, nil)
+	if err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+	if b == nil {
+		t.Fatal("got nil Backend")
+	}
+}
+
+func TestNewStaticInvalidRoutes(t *testing.T) {
+	cfg := config.Registry{Backend: "static", Static: config.Static{Routes: "not a route"}}
+	if _, err := New(cfg, nil, 0, nil); err == nil {
+		t.Fatal("got nil error, want one for an invalid static route")
+	}
+}
@@ -0,0 +1,63 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/eBay/fabio/proxy"
+	"github.com/eBay/fabio/registry"
+	"github.com/hashicorp/consul/api"
+)
+
+// Backend adapts Consul to the registry.Backend interface, one of several
+// pluggable route sources selected via registry.backend.
+type Backend struct {
+	client      *api.Client
+	tagPrefix   string
+	status      []string
+	dcIndex     int
+	datacenters []string
+	connect     bool
+	dcPolicy    string
+	watchMode   string
+	registrar   *Registrar
+}
+
+// NewBackend creates a Consul-backed registry.Backend. If connect is true
+// and p is non-nil, it also wires p.Transport to dial Connect-tagged
+// upstreams over the mTLS transport connectUpstream fetched for them,
+// instead of p's shared, plaintext transport.
+func NewBackend(client *api.Client, tagPrefix string, status []string, dcIndex int, datacenters []string, connect bool, dcPolicy, watchMode string, reg RegistrarConfig, p *proxy.Proxy) *Backend {
+	if connect && p != nil {
+		p.Transport = func(t *proxy.Target) http.RoundTripper {
+			tr, _ := LookupConnectTransport(t.URL.Host)
+			return tr
+		}
+	}
+
+	return &Backend{
+		client:      client,
+		tagPrefix:   tagPrefix,
+		status:      status,
+		dcIndex:     dcIndex,
+		datacenters: datacenters,
+		connect:     connect,
+		dcPolicy:    dcPolicy,
+		watchMode:   watchMode,
+		registrar:   NewRegistrar(client, reg),
+	}
+}
+
+// Subscribe streams the routes fabio should serve from Consul until ctx
+// is canceled.
+func (b *Backend) Subscribe(ctx context.Context) (<-chan []registry.Route, error) {
+	routes := make(chan []registry.Route)
+	go watchRoutes(ctx, b.client, b.tagPrefix, b.status, routes, b.dcIndex, b.datacenters, b.connect, b.dcPolicy, b.watchMode)
+	return routes, nil
+}
+
+// Register registers fabio itself with the local Consul agent.
+func (b *Backend) Register() error { return b.registrar.Register() }
+
+// Deregister removes fabio's own service registration.
+func (b *Backend) Deregister() error { return b.registrar.Deregister() }
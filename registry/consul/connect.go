@@ -0,0 +1,70 @@
+package consul
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// connectTransports caches the mTLS transports built for Consul Connect
+// upstreams, keyed by "addr:port" of the sidecar proxy. Entries are
+// (re)built by connectUpstream whenever buildRoutes finds a passing
+// instance tagged with proto=connect.
+var (
+	connectMu         sync.RWMutex
+	connectTransports = map[string]http.RoundTripper{}
+)
+
+// LookupConnectTransport returns the mTLS transport registered for a
+// Connect upstream at hostport, if any.
+func LookupConnectTransport(hostport string) (http.RoundTripper, bool) {
+	connectMu.RLock()
+	defer connectMu.RUnlock()
+	tr, ok := connectTransports[hostport]
+	return tr, ok
+}
+
+// connectUpstream fetches a leaf certificate and the current CA roots for
+// serviceID from the local agent, builds an mTLS transport from them and
+// registers it under hostport so the proxy can find it later.
+func connectUpstream(client *api.Client, serviceID, hostport string) error {
+	leaf, _, err := client.Agent().ConnectCALeaf(serviceID, nil)
+	if err != nil {
+		return fmt.Errorf("consul: error fetching leaf cert for %s: %s", serviceID, err)
+	}
+
+	roots, _, err := client.Agent().ConnectCARoots(nil)
+	if err != nil {
+		return fmt.Errorf("consul: error fetching CA roots: %s", err)
+	}
+
+	cert, err := tls.X509KeyPair([]byte(leaf.CertPEM), []byte(leaf.PrivateKeyPEM))
+	if err != nil {
+		return fmt.Errorf("consul: error parsing leaf cert for %s: %s", serviceID, err)
+	}
+
+	pool := x509.NewCertPool()
+	for _, root := range roots.Roots {
+		if !pool.AppendCertsFromPEM([]byte(root.RootCertPEM)) {
+			log.Printf("[WARN] consul: could not parse CA root %s", root.ID)
+		}
+	}
+
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			RootCAs:      pool,
+			ServerName:   serviceID,
+		},
+	}
+
+	connectMu.Lock()
+	connectTransports[hostport] = tr
+	connectMu.Unlock()
+	return nil
+}
@@ -0,0 +1,118 @@
+package consul
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ttlSlack multiplies CheckInterval to get the TTL fabio registers its
+// health check with. Without slack, ordinary scheduler jitter or a slow
+// /health round trip would let the TTL expire between renewals and flap
+// the service to critical even though fabio is healthy.
+const ttlSlack = 2
+
+// RegistrarConfig controls how fabio registers itself with the local
+// Consul agent.
+type RegistrarConfig struct {
+	Enabled       bool
+	ServiceAddr   string
+	ServiceName   string
+	ServiceTags   []string
+	CheckInterval time.Duration
+	CheckTimeout  time.Duration
+
+	// CheckDeregisterCriticalServiceAfter tells the agent to
+	// automatically deregister fabio if its health check has been
+	// critical for this long, in case a graceful Deregister is missed.
+	CheckDeregisterCriticalServiceAfter time.Duration
+}
+
+// Registrar registers fabio itself as a Consul service, using a TTL
+// health check driven by fabio's own /health endpoint. Advertising a
+// urlprefix- tag in ServiceTags is enough to make fabio discoverable the
+// same way as any other service.
+type Registrar struct {
+	client *api.Client
+	cfg    RegistrarConfig
+	id     string
+}
+
+// NewRegistrar creates a Registrar for the given agent client and config.
+func NewRegistrar(client *api.Client, cfg RegistrarConfig) *Registrar {
+	return &Registrar{client: client, cfg: cfg, id: "fabio-" + cfg.ServiceAddr}
+}
+
+// Register registers fabio with the local agent and starts a background
+// goroutine that reflects the result of fabio's own /health endpoint into
+// the service's TTL check.
+func (r *Registrar) Register() error {
+	if !r.cfg.Enabled {
+		return nil
+	}
+
+	host, portStr, err := net.SplitHostPort(r.cfg.ServiceAddr)
+	if err != nil {
+		return fmt.Errorf("consul: invalid registry.consul.register.addr %q: %s", r.cfg.ServiceAddr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("consul: invalid registry.consul.register.addr %q: %s", r.cfg.ServiceAddr, err)
+	}
+
+	reg := &api.AgentServiceRegistration{
+		ID:      r.id,
+		Name:    r.cfg.ServiceName,
+		Tags:    r.cfg.ServiceTags,
+		Address: host,
+		Port:    port,
+		Check: &api.AgentServiceCheck{
+			TTL:                            (ttlSlack * r.cfg.CheckInterval).String(),
+			Timeout:                        r.cfg.CheckTimeout.String(),
+			DeregisterCriticalServiceAfter: r.cfg.CheckDeregisterCriticalServiceAfter.String(),
+		},
+	}
+
+	if err := r.client.Agent().ServiceRegister(reg); err != nil {
+		return fmt.Errorf("consul: error registering service: %s", err)
+	}
+
+	go r.watchHealth()
+	return nil
+}
+
+// watchHealth polls fabio's own /health endpoint and reflects the result
+// into the TTL check on every CheckInterval, so Consul only considers
+// fabio healthy while it is actually serving.
+func (r *Registrar) watchHealth() {
+	checkID := "service:" + r.id
+	url := fmt.Sprintf("http://%s/health", r.cfg.ServiceAddr)
+
+	for range time.Tick(r.cfg.CheckInterval) {
+		resp, err := http.Get(url)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			r.client.Agent().UpdateTTL(checkID, "fabio: /health check failed", api.HealthCritical)
+		} else {
+			r.client.Agent().UpdateTTL(checkID, "fabio: ok", api.HealthPassing)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+// Deregister removes fabio's own service registration. It must be called
+// before proxy.ShuttingDown() starts failing requests, so peers stop
+// routing to this instance as soon as fabio starts to shut down.
+func (r *Registrar) Deregister() error {
+	if !r.cfg.Enabled {
+		return nil
+	}
+	log.Printf("[INFO] consul: Deregistering service %s", r.id)
+	return r.client.Agent().ServiceDeregister(r.id)
+}
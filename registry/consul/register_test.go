@@ -0,0 +1,16 @@
+package consul
+
+import "testing"
+
+func TestNewRegistrarID(t *testing.T) {
+	r := NewRegistrar(nil, RegistrarConfig{ServiceAddr: "1.2.3.4:5678"})
+	if got, want := r.id, "fabio-1.2.3.4:5678"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestTTLSlackLeavesHeadroomOverCheckInterval(t *testing.T) {
+	if ttlSlack <= 1 {
+		t.Fatalf("ttlSlack must be > 1 to leave headroom over CheckInterval, got %d", ttlSlack)
+	}
+}
@@ -1,99 +1,214 @@
 package consul
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/eBay/fabio/registry"
 	"github.com/hashicorp/consul/api"
 )
 
-// watchServices monitors the consul health checks and creates a new configuration
-// on every change.
-func watchServices(client *api.Client, tagPrefix string, status []string, config chan string, dcIndex int, datacenters []string) {
+// watchServices monitors the consul health checks and sends the rendered
+// "route add" config on every change, until ctx is canceled. It is the
+// legacy entry point kept for callers that still speak in config
+// strings; Backend.Subscribe uses watchRoutes directly to get
+// structured registry.Route values instead. watchMode selects how:
+// "global" polls Health().State("any") on every change, as fabio has
+// always done; "per-service" fans out to one blocking watch per
+// service instead, see watchServicesPerService.
+func watchServices(ctx context.Context, client *api.Client, tagPrefix string, status []string, config chan string, dcIndex int, datacenters []string, connectEnabled bool, dcPolicy, watchMode string) {
+	routes := make(chan []registry.Route)
+	go watchRoutes(ctx, client, tagPrefix, status, routes, dcIndex, datacenters, connectEnabled, dcPolicy, watchMode)
+	for rs := range routes {
+		select {
+		case config <- registry.Render(rs):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchRoutes dispatches to the configured watch strategy and streams
+// the routes it discovers as structured registry.Route values until ctx
+// is canceled.
+func watchRoutes(ctx context.Context, client *api.Client, tagPrefix string, status []string, routes chan []registry.Route, dcIndex int, datacenters []string, connectEnabled bool, dcPolicy, watchMode string) {
+	if watchMode == "per-service" {
+		watchServicesPerService(ctx, client, tagPrefix, status, routes, dcIndex, datacenters, connectEnabled, dcPolicy)
+		return
+	}
+	watchServicesGlobal(ctx, client, tagPrefix, status, routes, dcIndex, datacenters, connectEnabled, dcPolicy)
+}
+
+// watchServicesGlobal is the original watch strategy: it polls the health
+// state of every service in the local datacenter, and in every remote one
+// unless dcPolicy is "local", on each change, until ctx is canceled.
+//
+// dcPolicy controls which datacenters are considered when building routes:
+//
+//   local    - only the local datacenter (datacenters[dcIndex]) is used.
+//   failover - remote datacenters are only used for a service that has no
+//              passing instance in the local datacenter.
+//   all      - every datacenter is used, as fabio has always done.
+func watchServicesGlobal(ctx context.Context, client *api.Client, tagPrefix string, status []string, routes chan []registry.Route, dcIndex int, datacenters []string, connectEnabled bool, dcPolicy string) {
 	var lastIndex uint64
+	localDC := datacenters[dcIndex]
 
 	for {
-		var all_checks []*api.HealthCheck
-		q := &api.QueryOptions{RequireConsistent: true, WaitIndex: lastIndex, Datacenter: datacenters[dcIndex]}
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		checksByDC := map[string][]*api.HealthCheck{}
+
+		q := &api.QueryOptions{RequireConsistent: true, WaitIndex: lastIndex, Datacenter: localDC}
 		checks, meta, err := client.Health().State("any", q)
 		if err != nil {
 			log.Printf("[WARN] consul: Error fetching health state. %v", err)
-			time.Sleep(time.Second)
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return
+			}
 			continue
 		}
-		for _, check := range checks {
-			all_checks = append(all_checks, check)
-		}
+		checksByDC[localDC] = checks
 		log.Printf("[WARN] consul: Health changed to #%d", meta.LastIndex)
 		lastIndex = meta.LastIndex
-		for i, dc := range datacenters {
-			if i == dcIndex {
-				continue
-			}
 
-			q := &api.QueryOptions{RequireConsistent: true, Datacenter: dc}
-			checks, _, err := client.Health().State("any", q)
-			if err != nil {
-				log.Printf("[WARN] consul: Error fetching health state. %v", err)
-				time.Sleep(time.Second)
-				continue
-			}
-			for _, check := range checks {
-				all_checks = append(all_checks, check)
+		if dcPolicy != "local" {
+			for i, dc := range datacenters {
+				if i == dcIndex {
+					continue
+				}
+
+				q := &api.QueryOptions{RequireConsistent: true, Datacenter: dc}
+				checks, _, err := client.Health().State("any", q)
+				if err != nil {
+					log.Printf("[WARN] consul: Error fetching health state. %v", err)
+					continue
+				}
+				checksByDC[dc] = checks
 			}
 		}
-		config <- servicesConfig(client, passingServices(all_checks, status), tagPrefix)
+
+		select {
+		case routes <- buildAllRoutes(client, checksByDC, localDC, dcPolicy, tagPrefix, status, connectEnabled):
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
-// servicesConfig determines which service instances have passing health checks
-// and then finds the ones which have tags with the right prefix to build the config from.
-func servicesConfig(client *api.Client, checks []*api.HealthCheck, tagPrefix string) string {
-	// map service name to list of service passing for which the health check is ok
-	m := map[string]map[string]bool{}
-	for _, check := range checks {
-		name, id := check.ServiceName, check.ServiceID
+// buildAllRoutes determines which service instances have passing health
+// checks in each datacenter and builds the routes for the ones which have
+// tags with the right prefix.
+func buildAllRoutes(client *api.Client, checksByDC map[string][]*api.HealthCheck, localDC, dcPolicy, tagPrefix string, status []string, connectEnabled bool) []registry.Route {
+	// map service name to datacenter to list of service ids for which the
+	// health check is ok
+	m := map[string]map[string]map[string]bool{}
+	for dc, checks := range checksByDC {
+		for _, check := range passingServices(checks, status) {
+			name, id := check.ServiceName, check.ServiceID
 
-		if _, ok := m[name]; !ok {
-			m[name] = map[string]bool{}
+			if _, ok := m[name]; !ok {
+				m[name] = map[string]map[string]bool{}
+			}
+			if _, ok := m[name][dc]; !ok {
+				m[name][dc] = map[string]bool{}
+			}
+			m[name][dc][id] = true
 		}
-		m[name][id] = true
 	}
 
-	var config []string
-	for name, passing := range m {
-		cfg := serviceConfig(client, name, passing, tagPrefix)
-		config = append(config, cfg...)
+	var routes []registry.Route
+	for name, byDC := range m {
+		routes = append(routes, buildRoutes(client, name, byDC, localDC, dcPolicy, tagPrefix, connectEnabled)...)
 	}
+	return applyWeights(routes)
+}
 
-	// sort config in reverse order to sort most specific config to the top
-	sort.Sort(sort.Reverse(sort.StringSlice(config)))
+// applyWeights fills in the Weight of routes that did not carry an
+// explicit weight= tag, splitting the remaining probability mass evenly
+// among them.
+func applyWeights(routes []registry.Route) []registry.Route {
+	type key struct{ service, src string }
+	groups := map[key][]int{}
+	for i, rt := range routes {
+		k := key{rt.Service, rt.Src}
+		groups[k] = append(groups[k], i)
+	}
 
-	return strings.Join(config, "\n")
+	for _, idxs := range groups {
+		var explicit float64
+		var unweighted []int
+		for _, i := range idxs {
+			if routes[i].Weight > 0 {
+				explicit += routes[i].Weight
+			} else {
+				unweighted = append(unweighted, i)
+			}
+		}
+		if len(unweighted) == 0 {
+			continue
+		}
+
+		remainder := 1 - explicit
+		if remainder < 0 {
+			remainder = 0
+		}
+		share := remainder / float64(len(unweighted))
+		for _, i := range unweighted {
+			routes[i].Weight = share
+		}
+	}
+	return routes
 }
 
-// serviceConfig constructs the config for all good instances of a single service.
-func serviceConfig(client *api.Client, name string, passing map[string]bool, tagPrefix string) (config []string) {
-	if name == "" || len(passing) == 0 {
-		return nil
+// chooseDatacenters returns, in the order buildRoutes should query them,
+// the datacenters a service's routes should be built from under dcPolicy:
+// just localDC for "local", every datacenter with a passing instance in
+// byDC for "all" or any other value, including an empty one, and localDC
+// alone unless it has none for "failover".
+func chooseDatacenters(byDC map[string]map[string]bool, localDC, dcPolicy string) []string {
+	if dcPolicy == "local" || (dcPolicy == "failover" && len(byDC[localDC]) > 0) {
+		return []string{localDC}
+	}
+
+	var dcs []string
+	for dc := range byDC {
+		dcs = append(dcs, dc)
 	}
+	sort.Strings(dcs)
+	return dcs
+}
 
-	datacenters, err := client.Catalog().Datacenters()
-	if err != nil {
-		log.Printf("[WARN] consul: Error getting datacenters. %s", err)
+// buildRoutes constructs the routes for all good instances of a single
+// service, choosing which datacenters to draw instances from according to
+// dcPolicy: local-only, local with remote failover, or all datacenters.
+func buildRoutes(client *api.Client, name string, byDC map[string]map[string]bool, localDC, dcPolicy, tagPrefix string, connectEnabled bool) (routes []registry.Route) {
+	if name == "" || len(byDC) == 0 {
 		return nil
 	}
 
-	for _, dc := range datacenters {
+	for _, dc := range chooseDatacenters(byDC, localDC, dcPolicy) {
+		passing := byDC[dc]
+		if len(passing) == 0 {
+			continue
+		}
+
 		q := &api.QueryOptions{RequireConsistent: true, Datacenter: dc}
 		svcs, _, err := client.Catalog().Service(name, "", q)
 		if err != nil {
 			log.Printf("[WARN] consul: Error getting catalog service %s. %v", name, err)
-			return nil
+			continue
 		}
 
 		env := map[string]string{
@@ -108,23 +223,80 @@ func serviceConfig(client *api.Client, name string, passing map[string]bool, tag
 			}
 
 			for _, tag := range svc.ServiceTags {
-				if host, path, ok := parseURLPrefixTag(tag, tagPrefix, env); ok {
-					name, addr, port := svc.ServiceName, svc.ServiceAddress, svc.ServicePort
+				host, path, ok := parseURLPrefixTag(tag, tagPrefix, env)
+				if !ok {
+					continue
+				}
 
-					// use consul node address if service address is not set
-					if addr == "" {
-						addr = svc.Address
-					}
+				addr, port := svc.ServiceAddress, svc.ServicePort
 
-					// add .local suffix on OSX for simple host names w/o domain
-					if runtime.GOOS == "darwin" && !strings.Contains(addr, ".") && !strings.HasSuffix(addr, ".local") {
-						addr += ".local"
+				// use consul node address if service address is not set
+				if addr == "" {
+					addr = svc.Address
+				}
+
+				// add .local suffix on OSX for simple host names w/o domain
+				if runtime.GOOS == "darwin" && !strings.Contains(addr, ".") && !strings.HasSuffix(addr, ".local") {
+					addr += ".local"
+				}
+
+				// a Connect-enabled sidecar dials the service over mTLS using
+				// a leaf certificate fetched from the Connect CA instead of
+				// the plaintext connection the proxy would otherwise use.
+				// Dst must be rendered as https:// for that transport to
+				// actually be consulted; http.Transport never negotiates
+				// TLS for a plain http:// URL.
+				scheme := "http"
+				if proto, ok := tagValue(tag, "proto"); connectEnabled && ok && proto == "connect" {
+					hostport := fmt.Sprintf("%s:%d", addr, port)
+					if err := connectUpstream(client, svc.ServiceID, hostport); err != nil {
+						log.Printf("[WARN] consul: %s", err)
+						continue
 					}
+					scheme = "https"
+				}
+
+				// tag the route with its datacenter so the route table can
+				// prefer the local one, unless it already is the local one
+				dcTag := ""
+				if dc != localDC {
+					dcTag = dc
+				}
 
-					config = append(config, fmt.Sprintf("route add %s %s%s http://%s:%d/ tags %q", name, host, path, addr, port, strings.Join(svc.ServiceTags, ",")))
+				var weight float64
+				if w, ok := tagValue(tag, "weight"); ok {
+					if f, err := strconv.ParseFloat(w, 64); err == nil {
+						weight = f
+					} else {
+						log.Printf("[WARN] consul: invalid weight tag %q on %s: %s", tag, svc.ServiceID, err)
+					}
 				}
+
+				sticky, _ := tagValue(tag, "sticky")
+
+				routes = append(routes, registry.Route{
+					Service: svc.ServiceName,
+					Src:     host + path,
+					Dst:     fmt.Sprintf("%s://%s:%d/", scheme, addr, port),
+					DC:      dcTag,
+					Weight:  weight,
+					Sticky:  sticky,
+					Tags:    svc.ServiceTags,
+				})
 			}
 		}
 	}
-	return config
+	return routes
+}
+
+// tagValue extracts the value of a "key=value" option from a Consul
+// service tag such as "urlprefix-/app weight=0.1 sticky=cookie:SID", or
+// returns ok=false if the option is not present on the tag.
+func tagValue(tag, key string) (value string, ok bool) {
+	for _, tok := range strings.Fields(tag) {
+		if strings.HasPrefix(tok, key+"=") {
+			return strings.TrimPrefix(tok, key+"="), true
+		}
+	}
+	return "", false
 }
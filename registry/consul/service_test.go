@@ -0,0 +1,95 @@
+package consul
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eBay/fabio/registry"
+)
+
+func TestTagValue(t *testing.T) {
+	tests := []struct {
+		tag, key string
+		value    string
+		ok       bool
+	}{
+		{"urlprefix-/app weight=0.1 sticky=cookie:SID", "weight", "0.1", true},
+		{"urlprefix-/app weight=0.1 sticky=cookie:SID", "sticky", "cookie:SID", true},
+		{"urlprefix-/app", "weight", "", false},
+		{"weight=", "weight", "", true},
+	}
+
+	for _, tt := range tests {
+		value, ok := tagValue(tt.tag, tt.key)
+		if got, want := ok, tt.ok; got != want {
+			t.Errorf("%q/%q: got ok=%v want %v", tt.tag, tt.key, got, want)
+			continue
+		}
+		if got, want := value, tt.value; got != want {
+			t.Errorf("%q/%q: got %q want %q", tt.tag, tt.key, got, want)
+		}
+	}
+}
+
+func TestApplyWeights(t *testing.T) {
+	in := []registry.Route{
+		{Service: "a", Src: "/a", Weight: 0},
+		{Service: "a", Src: "/a", Weight: 0},
+		{Service: "a", Src: "/a", Weight: 0.3},
+	}
+	out := applyWeights(in)
+	if got, want := out[0].Weight, 0.35; got != want {
+		t.Errorf("unweighted[0]: got %v want %v", got, want)
+	}
+	if got, want := out[1].Weight, 0.35; got != want {
+		t.Errorf("unweighted[1]: got %v want %v", got, want)
+	}
+	if got, want := out[2].Weight, 0.3; got != want {
+		t.Errorf("explicit: got %v want %v", got, want)
+	}
+}
+
+func TestApplyWeightsExplicitOverflow(t *testing.T) {
+	// explicit weights already sum past 1: the remaining routes get 0
+	// rather than a negative share.
+	in := []registry.Route{
+		{Service: "a", Src: "/a", Weight: 0.7},
+		{Service: "a", Src: "/a", Weight: 0.6},
+		{Service: "a", Src: "/a", Weight: 0},
+	}
+	out := applyWeights(in)
+	if got, want := out[2].Weight, 0.0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestChooseDatacenters(t *testing.T) {
+	byDC := map[string]map[string]bool{
+		"dc1": {"a": true},
+		"dc2": {"b": true},
+	}
+
+	tests := []struct {
+		name            string
+		byDC            map[string]map[string]bool
+		localDC, policy string
+		want            []string
+	}{
+		{"local", byDC, "dc1", "local", []string{"dc1"}},
+		{"all", byDC, "dc1", "all", []string{"dc1", "dc2"}},
+		{"failover with local instances", byDC, "dc1", "failover", []string{"dc1"}},
+		{
+			"failover with empty local DC",
+			map[string]map[string]bool{"dc1": {}, "dc2": {"b": true}},
+			"dc1", "failover",
+			[]string{"dc1", "dc2"},
+		},
+	}
+
+	for _, tt := range tests {
+		got := chooseDatacenters(tt.byDC, tt.localDC, tt.policy)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v want %v", tt.name, got, tt.want)
+		}
+	}
+}
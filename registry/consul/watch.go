@@ -0,0 +1,177 @@
+package consul
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/eBay/fabio/registry"
+	"github.com/hashicorp/consul/api"
+)
+
+// debounceWindow is how long watchServicesPerService waits for additional
+// check changes to arrive before regenerating the config. A burst of
+// flapping checks within the window produces a single regeneration
+// instead of one per check.
+const debounceWindow = 100 * time.Millisecond
+
+// rediscoverInterval is how often watchServicesPerService re-lists the
+// catalog to pick up newly registered services, since a blocking watch is
+// only started once a service is known.
+const rediscoverInterval = 10 * time.Second
+
+// drainPending empties any signals already queued on dirty without
+// blocking. Called after the debounce sleep, it keeps a burst of flapping
+// checks that queued up while we were asleep from triggering another
+// round immediately after this one.
+func drainPending(dirty chan struct{}) {
+	for {
+		select {
+		case <-dirty:
+		default:
+			return
+		}
+	}
+}
+
+// watchServicesPerService watches Consul with one blocking query per known
+// service instead of a single global Health().State("any") poll: a flap in
+// one service only pulls that service's checks, not every check in every
+// watched datacenter. Updates from all per-service watches are coalesced
+// through debounceWindow before the config is regenerated. It runs until
+// ctx is canceled.
+func watchServicesPerService(ctx context.Context, client *api.Client, tagPrefix string, status []string, routes chan []registry.Route, dcIndex int, datacenters []string, connectEnabled bool, dcPolicy string) {
+	localDC := datacenters[dcIndex]
+
+	var (
+		mu    sync.Mutex
+		byDC  = map[string]map[string][]*api.HealthCheck{} // dc -> service -> checks
+		known = map[string]bool{}                          // "dc/service" already being watched
+	)
+
+	dirty := make(chan struct{}, 1)
+	markDirty := func() {
+		select {
+		case dirty <- struct{}{}:
+		default:
+		}
+	}
+
+	watchService := func(name, dc string) {
+		var lastIndex uint64
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			q := &api.QueryOptions{RequireConsistent: true, WaitIndex: lastIndex, Datacenter: dc}
+			entries, meta, err := client.Health().Service(name, "", false, q)
+			if err != nil {
+				log.Printf("[WARN] consul: Error watching service %s in %s. %v", name, dc, err)
+				select {
+				case <-time.After(time.Second):
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			lastIndex = meta.LastIndex
+
+			checks := make([]*api.HealthCheck, len(entries))
+			for i, e := range entries {
+				checks[i] = &api.HealthCheck{
+					Node:        e.Node.Node,
+					ServiceID:   e.Service.ID,
+					ServiceName: e.Service.Service,
+					Status:      e.Checks.AggregatedStatus(),
+				}
+			}
+
+			mu.Lock()
+			if _, ok := byDC[dc]; !ok {
+				byDC[dc] = map[string][]*api.HealthCheck{}
+			}
+			byDC[dc][name] = checks
+			mu.Unlock()
+
+			markDirty()
+		}
+	}
+
+	discover := func() {
+		for i, dc := range datacenters {
+			if dcPolicy == "local" && i != dcIndex {
+				continue
+			}
+
+			q := &api.QueryOptions{RequireConsistent: true, Datacenter: dc}
+			svcs, _, err := client.Catalog().Services(q)
+			if err != nil {
+				log.Printf("[WARN] consul: Error listing services in %s. %v", dc, err)
+				continue
+			}
+
+			for name := range svcs {
+				key := dc + "/" + name
+
+				mu.Lock()
+				start := !known[key]
+				known[key] = true
+				mu.Unlock()
+
+				if start {
+					go watchService(name, dc)
+				}
+			}
+		}
+	}
+
+	discover()
+	go func() {
+		ticker := time.NewTicker(rediscoverInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				discover()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-dirty:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-time.After(debounceWindow):
+		case <-ctx.Done():
+			return
+		}
+		drainPending(dirty)
+
+		mu.Lock()
+		checksByDC := map[string][]*api.HealthCheck{}
+		for dc, byName := range byDC {
+			var checks []*api.HealthCheck
+			for _, c := range byName {
+				checks = append(checks, c...)
+			}
+			checksByDC[dc] = checks
+		}
+		mu.Unlock()
+
+		select {
+		case routes <- buildAllRoutes(client, checksByDC, localDC, dcPolicy, tagPrefix, status, connectEnabled):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
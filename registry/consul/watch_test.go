@@ -0,0 +1,23 @@
+package consul
+
+import "testing"
+
+func TestDrainPending(t *testing.T) {
+	dirty := make(chan struct{}, 4)
+	for i := 0; i < 3; i++ {
+		dirty <- struct{}{}
+	}
+
+	drainPending(dirty)
+
+	select {
+	case <-dirty:
+		t.Fatal("got a pending signal, want the channel drained")
+	default:
+	}
+}
+
+func TestDrainPendingEmptyChannel(t *testing.T) {
+	dirty := make(chan struct{}, 1)
+	drainPending(dirty) // must not block on an already-empty channel
+}
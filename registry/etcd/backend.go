@@ -0,0 +1,88 @@
+// Package etcd implements a registry.Backend that reads routes from etcd
+// keys under a path and reacts to changes via etcd's watch API.
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/coreos/etcd/client"
+	"github.com/eBay/fabio/registry"
+)
+
+// Backend is a registry.Backend backed by etcd. Each child key under path
+// holds one "route add" line.
+type Backend struct {
+	kapi client.KeysAPI
+	path string
+}
+
+// NewBackend creates an etcd-backed registry.Backend for the cluster at
+// addr, reading routes from the children of path.
+func NewBackend(addr, path string) (*Backend, error) {
+	c, err := client.New(client.Config{Endpoints: []string{addr}})
+	if err != nil {
+		return nil, fmt.Errorf("etcd: error connecting to %s: %s", addr, err)
+	}
+	return &Backend{kapi: client.NewKeysAPI(c), path: path}, nil
+}
+
+// Subscribe reads path once, then again after every change reported by
+// etcd's watch API, until ctx is canceled.
+func (b *Backend) Subscribe(ctx context.Context) (<-chan []registry.Route, error) {
+	routes := make(chan []registry.Route)
+	go b.watch(ctx, routes)
+	return routes, nil
+}
+
+func (b *Backend) watch(ctx context.Context, routes chan []registry.Route) {
+	b.load(ctx, routes)
+
+	watcher := b.kapi.Watcher(b.path, &client.WatcherOptions{Recursive: true})
+	for {
+		if _, err := watcher.Next(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[WARN] etcd: watch error on %s: %s", b.path, err)
+			continue
+		}
+		b.load(ctx, routes)
+	}
+}
+
+func (b *Backend) load(ctx context.Context, routes chan []registry.Route) {
+	resp, err := b.kapi.Get(ctx, b.path, &client.GetOptions{Recursive: true, Sort: true})
+	if err != nil {
+		log.Printf("[WARN] etcd: error reading %s: %s", b.path, err)
+		return
+	}
+
+	var lines []string
+	for _, node := range resp.Node.Nodes {
+		if !node.Dir {
+			lines = append(lines, node.Value)
+		}
+	}
+	sort.Strings(lines)
+
+	rs, err := registry.ParseRoutes(strings.Join(lines, "\n"))
+	if err != nil {
+		log.Printf("[WARN] etcd: error parsing %s: %s", b.path, err)
+		return
+	}
+
+	select {
+	case routes <- rs:
+	case <-ctx.Done():
+	}
+}
+
+// Register is a no-op: fabio does not register itself through etcd.
+func (b *Backend) Register() error { return nil }
+
+// Deregister is a no-op: fabio does not register itself through etcd.
+func (b *Backend) Deregister() error { return nil }
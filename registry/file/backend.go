@@ -0,0 +1,92 @@
+// Package file implements a registry.Backend that reads routes from a
+// local file and re-reads it whenever the file is written.
+package file
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/eBay/fabio/registry"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Backend is a registry.Backend backed by a single file of "route add"
+// lines.
+type Backend struct {
+	path string
+}
+
+// NewBackend creates a file-backed registry.Backend for the file at path.
+func NewBackend(path string) (*Backend, error) {
+	return &Backend{path: path}, nil
+}
+
+// Subscribe reads path once, then again every time it is written to,
+// until ctx is canceled.
+func (b *Backend) Subscribe(ctx context.Context) (<-chan []registry.Route, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("file: error creating watcher: %s", err)
+	}
+	if err := watcher.Add(b.path); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("file: error watching %s: %s", b.path, err)
+	}
+
+	routes := make(chan []registry.Route)
+	go b.watch(ctx, watcher, routes)
+	return routes, nil
+}
+
+func (b *Backend) watch(ctx context.Context, watcher *fsnotify.Watcher, routes chan []registry.Route) {
+	defer watcher.Close()
+
+	b.load(ctx, routes)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				b.load(ctx, routes)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[WARN] file: watch error on %s: %s", b.path, err)
+		}
+	}
+}
+
+func (b *Backend) load(ctx context.Context, routes chan []registry.Route) {
+	data, err := ioutil.ReadFile(b.path)
+	if err != nil {
+		log.Printf("[WARN] file: error reading %s: %s", b.path, err)
+		return
+	}
+
+	rs, err := registry.ParseRoutes(string(data))
+	if err != nil {
+		log.Printf("[WARN] file: error parsing %s: %s", b.path, err)
+		return
+	}
+
+	select {
+	case routes <- rs:
+	case <-ctx.Done():
+	}
+}
+
+// Register is a no-op: the file backend has no self-registration.
+func (b *Backend) Register() error { return nil }
+
+// Deregister is a no-op: the file backend has no self-registration.
+func (b *Backend) Deregister() error { return nil }
@@ -0,0 +1,67 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBackendSubscribeReloadsOnWrite(t *testing.T) {
+	f, err := ioutil.TempFile("", "fabio-file-backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("route add svc / http://127.0.0.1:6666/\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	b, err := NewBackend(f.Name())
+	if err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	routes, err := b.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+
+	select {
+	case rs := <-routes:
+		if got, want := len(rs), 1; got != want {
+			t.Fatalf("got %d routes want %d", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the initial load")
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte("route add svc / http://127.0.0.1:6666/\nroute add svc2 / http://127.0.0.1:7777/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case rs := <-routes:
+		if got, want := len(rs), 2; got != want {
+			t.Fatalf("got %d routes want %d", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the reload")
+	}
+}
+
+func TestBackendNoSelfRegistration(t *testing.T) {
+	b, _ := NewBackend("/nonexistent")
+	if err := b.Register(); err != nil {
+		t.Errorf("got %v want nil", err)
+	}
+	if err := b.Deregister(); err != nil {
+		t.Errorf("got %v want nil", err)
+	}
+}
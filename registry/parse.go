@@ -0,0 +1,76 @@
+package registry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseRoute parses a single "route add" line in the format Render
+// produces back into a Route. Backends that read routes as text, such as
+// a watched file or a static config value, use it to turn each line back
+// into the structured value Consul would have produced directly. The
+// quoted tags value must be a single whitespace-free field; one that
+// contains whitespace is rejected as invalid.
+func ParseRoute(line string) (Route, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "route" || fields[1] != "add" {
+		return Route{}, fmt.Errorf("registry: invalid route %q", line)
+	}
+
+	rt := Route{Service: fields[2], Src: fields[3], Dst: fields[4]}
+
+	for i := 5; i < len(fields); i++ {
+		tok := fields[i]
+		switch {
+		case strings.HasPrefix(tok, "dc="):
+			rt.DC = strings.TrimPrefix(tok, "dc=")
+
+		case strings.HasPrefix(tok, "weight="):
+			w, err := strconv.ParseFloat(strings.TrimPrefix(tok, "weight="), 64)
+			if err != nil {
+				return Route{}, fmt.Errorf("registry: invalid weight in %q: %s", line, err)
+			}
+			rt.Weight = w
+
+		case strings.HasPrefix(tok, "sticky="):
+			rt.Sticky = strings.TrimPrefix(tok, "sticky=")
+
+		case tok == "tags":
+			i++
+			if i >= len(fields) {
+				return Route{}, fmt.Errorf("registry: missing tags value in %q", line)
+			}
+			tags, err := strconv.Unquote(fields[i])
+			if err != nil {
+				return Route{}, fmt.Errorf("registry: invalid tags in %q: %s", line, err)
+			}
+			if tags != "" {
+				rt.Tags = strings.Split(tags, ",")
+			}
+
+		default:
+			return Route{}, fmt.Errorf("registry: unexpected token %q in %q", tok, line)
+		}
+	}
+	return rt, nil
+}
+
+// ParseRoutes parses a newline-separated block of "route add" lines, as
+// found in registry.static.routes or a file read by the file backend.
+// Blank lines are skipped.
+func ParseRoutes(text string) ([]Route, error) {
+	var routes []Route
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		rt, err := ParseRoute(line)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, rt)
+	}
+	return routes, nil
+}
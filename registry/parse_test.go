@@ -0,0 +1,55 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+	tests := []struct {
+		in   string
+		out  Route
+		fail bool
+	}{
+		{
+			in:  "route add svc /foo http://1.2.3.4:80/",
+			out: Route{Service: "svc", Src: "/foo", Dst: "http://1.2.3.4:80/"},
+		},
+		{
+			in:  `route add svc /foo http://1.2.3.4:80/ dc=dc2 weight=0.25 sticky=cookie:SID tags "a,b"`,
+			out: Route{Service: "svc", Src: "/foo", Dst: "http://1.2.3.4:80/", DC: "dc2", Weight: 0.25, Sticky: "cookie:SID", Tags: []string{"a", "b"}},
+		},
+		{in: "route add svc /foo", fail: true},
+		{in: "route add svc /foo http://1.2.3.4:80/ weight=abc", fail: true},
+		{in: "route add svc /foo http://1.2.3.4:80/ tags", fail: true},
+		{in: "route add svc /foo http://1.2.3.4:80/ tags notquoted", fail: true},
+		{in: "route add svc /foo http://1.2.3.4:80/ bogus=1", fail: true},
+	}
+
+	for _, tt := range tests {
+		rt, err := ParseRoute(tt.in)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%q: got nil error, want one", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: got %v want nil", tt.in, err)
+			continue
+		}
+		if got, want := rt, tt.out; !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %+v want %+v", tt.in, got, want)
+		}
+	}
+}
+
+func TestParseRoutesSkipsBlankLines(t *testing.T) {
+	out, err := ParseRoutes("\nroute add a /a http://1/\n\nroute add b /b http://2/\n")
+	if err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+	if got, want := len(out), 2; got != want {
+		t.Fatalf("got %d routes want %d", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Render turns a set of routes into the newline-separated "route add"
+// command script that fabio's config parser understands. ParseRoute is
+// its inverse for routes whose tags contain no whitespace; ParseRoute
+// rejects a tags value with whitespace in it.
+func Render(routes []Route) string {
+	var lines []string
+	for _, rt := range routes {
+		line := fmt.Sprintf("route add %s %s %s", rt.Service, rt.Src, rt.Dst)
+		if rt.DC != "" {
+			line += fmt.Sprintf(" dc=%s", rt.DC)
+		}
+		if rt.Weight > 0 {
+			line += fmt.Sprintf(" weight=%g", rt.Weight)
+		}
+		if rt.Sticky != "" {
+			line += fmt.Sprintf(" sticky=%s", rt.Sticky)
+		}
+		if len(rt.Tags) > 0 {
+			line += fmt.Sprintf(" tags %q", strings.Join(rt.Tags, ","))
+		}
+		lines = append(lines, line)
+	}
+
+	// sort in reverse order so the most specific routes end up first, as
+	// the consul backend has always done
+	sort.Sort(sort.Reverse(sort.StringSlice(lines)))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,30 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	in := []Route{
+		{Service: "a", Src: "/a", Dst: "http://1.2.3.4:80/"},
+		{Service: "b", Src: "/b", Dst: "http://1.2.3.4:81/", DC: "dc2", Weight: 0.5, Sticky: "ip", Tags: []string{"x", "y"}},
+	}
+	want := `route add b /b http://1.2.3.4:81/ dc=dc2 weight=0.5 sticky=ip tags "x,y"` + "\n" + `route add a /a http://1.2.3.4:80/`
+	if got := Render(in); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestRenderParseRouteRoundTrip(t *testing.T) {
+	in := []Route{
+		{Service: "svc", Src: "/foo", Dst: "http://1.2.3.4:80/", DC: "dc2", Weight: 0.25, Sticky: "cookie:SID", Tags: []string{"a", "b"}},
+	}
+	out, err := ParseRoutes(Render(in))
+	if err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+	if got, want := out, in; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+}
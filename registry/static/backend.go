@@ -0,0 +1,40 @@
+// Package static implements a registry.Backend backed by a fixed set of
+// routes supplied directly in config, for environments with no service
+// discovery at all.
+package static
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eBay/fabio/registry"
+)
+
+// Backend is a registry.Backend whose routes never change after startup.
+type Backend struct {
+	routes []registry.Route
+}
+
+// NewBackend parses routes, a newline-separated block of "route add"
+// lines, into a static registry.Backend.
+func NewBackend(routes string) (*Backend, error) {
+	rs, err := registry.ParseRoutes(routes)
+	if err != nil {
+		return nil, fmt.Errorf("static: %s", err)
+	}
+	return &Backend{routes: rs}, nil
+}
+
+// Subscribe sends the configured routes once. There is nothing to watch
+// for changes, so the channel never receives a second value.
+func (b *Backend) Subscribe(ctx context.Context) (<-chan []registry.Route, error) {
+	routes := make(chan []registry.Route, 1)
+	routes <- b.routes
+	return routes, nil
+}
+
+// Register is a no-op: the static backend has no self-registration.
+func (b *Backend) Register() error { return nil }
+
+// Deregister is a no-op: the static backend has no self-registration.
+func (b *Backend) Deregister() error { return nil }
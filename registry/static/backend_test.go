@@ -0,0 +1,42 @@
+package static
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBackendSubscribe(t *testing.T) {
+	b, err := NewBackend("route add svc / http://127.0.0.1:6666/")
+	if err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+
+	routes, err := b.Subscribe(context.Background())
+	if err != nil {
+		t.Fatalf("got %v want nil", err)
+	}
+
+	rs := <-routes
+	if got, want := len(rs), 1; got != want {
+		t.Fatalf("got %d routes want %d", got, want)
+	}
+	if got, want := rs[0].Service, "svc"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestNewBackendInvalidRoutes(t *testing.T) {
+	if _, err := NewBackend("not a route"); err == nil {
+		t.Fatal("got nil error, want one")
+	}
+}
+
+func TestBackendNoSelfRegistration(t *testing.T) {
+	b, _ := NewBackend("")
+	if err := b.Register(); err != nil {
+		t.Errorf("got %v want nil", err)
+	}
+	if err := b.Deregister(); err != nil {
+		t.Errorf("got %v want nil", err)
+	}
+}
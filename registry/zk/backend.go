@@ -0,0 +1,94 @@
+// Package zk implements a registry.Backend that reads routes from
+// Zookeeper znodes under a path and reacts to changes via zk watches.
+package zk
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eBay/fabio/registry"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+// Backend is a registry.Backend backed by Zookeeper. Each child znode
+// under path holds one "route add" line.
+type Backend struct {
+	conn *zk.Conn
+	path string
+}
+
+// NewBackend creates a Zookeeper-backed registry.Backend for the cluster
+// at addr, reading routes from the children of path.
+func NewBackend(addr, path string) (*Backend, error) {
+	conn, _, err := zk.Connect(strings.Split(addr, ","), 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("zk: error connecting to %s: %s", addr, err)
+	}
+	return &Backend{conn: conn, path: path}, nil
+}
+
+// Subscribe reads path once, then again after every change reported by a
+// Zookeeper watch, until ctx is canceled.
+func (b *Backend) Subscribe(ctx context.Context) (<-chan []registry.Route, error) {
+	routes := make(chan []registry.Route)
+	go b.watch(ctx, routes)
+	return routes, nil
+}
+
+func (b *Backend) watch(ctx context.Context, routes chan []registry.Route) {
+	for {
+		children, _, events, err := b.conn.ChildrenW(b.path)
+		if err != nil {
+			log.Printf("[WARN] zk: error watching %s: %s", b.path, err)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		b.load(ctx, children, routes)
+
+		select {
+		case <-events:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *Backend) load(ctx context.Context, children []string, routes chan []registry.Route) {
+	sort.Strings(children)
+
+	var lines []string
+	for _, c := range children {
+		data, _, err := b.conn.Get(b.path + "/" + c)
+		if err != nil {
+			log.Printf("[WARN] zk: error reading %s/%s: %s", b.path, c, err)
+			continue
+		}
+		lines = append(lines, string(data))
+	}
+
+	rs, err := registry.ParseRoutes(strings.Join(lines, "\n"))
+	if err != nil {
+		log.Printf("[WARN] zk: error parsing %s: %s", b.path, err)
+		return
+	}
+
+	select {
+	case routes <- rs:
+	case <-ctx.Done():
+	}
+}
+
+// Register is a no-op: fabio does not register itself through Zookeeper.
+func (b *Backend) Register() error { return nil }
+
+// Deregister is a no-op: fabio does not register itself through Zookeeper.
+func (b *Backend) Deregister() error { return nil }